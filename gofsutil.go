@@ -0,0 +1,109 @@
+package gofsutil
+
+import "context"
+
+// EntryScanFunc is used to scan mount table entries produced by
+// ReadProcMountsFrom into Info objects.
+type EntryScanFunc func(ctx context.Context, entry string) (info Info, valid bool)
+
+// Info describes a single mounted filesystem as read from
+// /proc/self/mountinfo.
+type Info struct {
+	Device string
+	Path   string
+	Source string
+	Type   string
+	Opts   []string
+
+	// MountID and ParentID are the kernel-assigned unique ID of this
+	// mount and its parent, per mountinfo(5).
+	MountID  string
+	ParentID string
+
+	// MajorMinor is the st_dev of this mount's backing device, formatted
+	// "major:minor". Two mounts sharing a MajorMinor are backed by the
+	// same block device, which is what GetMountRefs uses to find bind
+	// mounts of the same device.
+	MajorMinor string
+
+	// Root is the pathname of the directory in the filesystem which
+	// forms the root of this mount.
+	Root string
+
+	// OptionalFields holds the zero-or-more space-separated fields
+	// between the mount options and the "-" separator, e.g.
+	// "shared:2" or "master:1". IsShared inspects these.
+	OptionalFields []string
+
+	// SuperOptions are the filesystem-type-specific mount options,
+	// which can differ from Opts (the per-mount options).
+	SuperOptions []string
+}
+
+// FS exposes the gofsutil operations as methods on a configurable
+// object so callers can override behavior (and tests can stub it out)
+// without relying on package-level globals. The zero value, &FS{}, is
+// ready to use.
+type FS struct {
+	// ScanEntry parses a single mountinfo line into an Info object.
+	// defaultEntryScanFunc is used when nil.
+	ScanEntry EntryScanFunc
+
+	// DisableFsck skips the pre-mount fsck check formatAndMount would
+	// otherwise run against already-formatted ext2/ext3/ext4/xfs
+	// devices.
+	DisableFsck bool
+
+	// fsckArgs are extra arguments passed to fsck ahead of the device
+	// path, set via WithFsckOptions.
+	fsckArgs []string
+
+	// mkfsArgs are extra arguments passed to mkfs ahead of the device
+	// path, set via WithMkfsArgs.
+	mkfsArgs []string
+
+	// mkfsBinaries overrides the mkfs binary used for a given fsType,
+	// set via WithMkfsBinary.
+	mkfsBinaries map[string]string
+
+	// useSystemdScope and systemdUnitPrefix configure running mounts
+	// inside a transient systemd scope unit, set via WithSystemdScope.
+	useSystemdScope   bool
+	systemdUnitPrefix string
+
+	// execImpl is the Exec implementation used for every external
+	// command gofsutil runs, set via WithExec. RealExec is used when
+	// nil.
+	execImpl Exec
+
+	// mountsPath overrides procMountsPath as the file getMounts reads,
+	// so tests can point GetMountRefs/IsShared/getMounts at a fixture
+	// instead of the real /proc/self/mountinfo. procMountsPath is used
+	// when empty.
+	mountsPath string
+}
+
+// WithExec overrides the Exec implementation used for every external
+// command gofsutil runs (blkid, mkfs, mount, fsck, resize tools),
+// letting tests substitute a FakeExec instead of shelling out.
+func (fs *FS) WithExec(e Exec) *FS {
+	fs.execImpl = e
+	return fs
+}
+
+// execer returns the Exec to use, defaulting to RealExec.
+func (fs *FS) execer() Exec {
+	if fs.execImpl != nil {
+		return fs.execImpl
+	}
+	return RealExec{}
+}
+
+// scanEntry returns the EntryScanFunc to use, defaulting to
+// defaultEntryScanFunc.
+func (fs *FS) scanEntry() EntryScanFunc {
+	if fs.ScanEntry != nil {
+		return fs.ScanEntry
+	}
+	return defaultEntryScanFunc
+}
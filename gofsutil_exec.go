@@ -0,0 +1,117 @@
+package gofsutil
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Cmd is the subset of *exec.Cmd that gofsutil's format/mount code
+// needs from a single command invocation.
+type Cmd interface {
+	CombinedOutput() ([]byte, error)
+	Output() ([]byte, error)
+	Run() error
+}
+
+// Exec abstracts command execution so the format/mount decision tree
+// can be unit tested without shelling out to real blkid/mkfs/mount/
+// fsck binaries. It mirrors the exec abstraction used by the upstream
+// Kubernetes mount package.
+type Exec interface {
+	CommandContext(ctx context.Context, name string, args ...string) Cmd
+}
+
+// RealExec is the default Exec, backed by os/exec.
+type RealExec struct{}
+
+// CommandContext implements Exec.
+func (RealExec) CommandContext(ctx context.Context, name string, args ...string) Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// exitCoder is satisfied by *exec.ExitError (and by FakeExec's scripted
+// errors, so tests can exercise exit-code-sensitive paths like Fsck).
+type exitCoder interface {
+	ExitCode() int
+}
+
+// exitCodeOf returns err's process exit code, or -1 if err doesn't
+// carry one.
+func exitCodeOf(err error) int {
+	if ec, ok := err.(exitCoder); ok {
+		return ec.ExitCode()
+	}
+	return -1
+}
+
+// FakeInvocation records a single CommandContext call made against a
+// FakeExec.
+type FakeInvocation struct {
+	Name string
+	Args []string
+}
+
+// FakeCmdResult scripts what a FakeExec invocation returns. ExitCode is
+// only used when Err is non-nil, so scripted results can exercise
+// exit-code-sensitive callers such as Fsck the same way *exec.ExitError
+// does.
+type FakeCmdResult struct {
+	Output   []byte
+	Err      error
+	ExitCode int
+}
+
+// FakeExec is an Exec that records invocations and returns scripted
+// results instead of running real commands.
+type FakeExec struct {
+	// Results is consulted in order, one result per CommandContext
+	// call; once exhausted, a zero-value result (empty output, no
+	// error) is returned for any further calls.
+	Results []FakeCmdResult
+
+	Invocations []FakeInvocation
+}
+
+// CommandContext implements Exec.
+func (f *FakeExec) CommandContext(ctx context.Context, name string, args ...string) Cmd {
+	i := len(f.Invocations)
+	f.Invocations = append(f.Invocations, FakeInvocation{Name: name, Args: args})
+
+	var result FakeCmdResult
+	if i < len(f.Results) {
+		result = f.Results[i]
+	}
+	return &fakeCmd{result: result}
+}
+
+// fakeCmd is the Cmd returned by FakeExec.
+type fakeCmd struct {
+	result FakeCmdResult
+}
+
+func (c *fakeCmd) CombinedOutput() ([]byte, error) {
+	if c.result.Err != nil {
+		return c.result.Output, fakeExitError{c.result.ExitCode, c.result.Err}
+	}
+	return c.result.Output, nil
+}
+
+func (c *fakeCmd) Output() ([]byte, error) {
+	return c.CombinedOutput()
+}
+
+func (c *fakeCmd) Run() error {
+	_, err := c.CombinedOutput()
+	return err
+}
+
+// fakeExitError wraps a scripted error with a scripted exit code, so it
+// satisfies exitCoder the same way *exec.ExitError does.
+type fakeExitError struct {
+	exitCode int
+	err      error
+}
+
+func (e fakeExitError) Error() string { return e.err.Error() }
+func (e fakeExitError) Unwrap() error { return e.err }
+func (e fakeExitError) ExitCode() int { return e.exitCode }
@@ -0,0 +1,197 @@
+package gofsutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetDiskFormatUsesExec(t *testing.T) {
+	fake := &FakeExec{Results: []FakeCmdResult{{Output: []byte("TYPE=ext4\n")}}}
+	fs := (&FS{}).WithExec(fake)
+
+	got, err := fs.getDiskFormat(context.Background(), "/dev/sdb")
+	if err != nil {
+		t.Fatalf("getDiskFormat: %v", err)
+	}
+	if got != "ext4" {
+		t.Fatalf("fsType = %q, want ext4", got)
+	}
+	if len(fake.Invocations) != 1 || fake.Invocations[0].Name != "blkid" {
+		t.Fatalf("Invocations = %+v, want a single blkid call", fake.Invocations)
+	}
+}
+
+func TestGetDiskFormatPartitionTableNoFilesystem(t *testing.T) {
+	fake := &FakeExec{Results: []FakeCmdResult{{Output: []byte("PTTYPE=gpt\n")}}}
+	fs := (&FS{}).WithExec(fake)
+
+	got, err := fs.getDiskFormat(context.Background(), "/dev/sdb")
+	if got != "" {
+		t.Fatalf("fsType = %q, want empty", got)
+	}
+	var ptErr *ErrPartitionTableDetected
+	if !errors.As(err, &ptErr) {
+		t.Fatalf("error = %v, want *ErrPartitionTableDetected", err)
+	}
+}
+
+func TestGetDiskFormatBusyDeviceIsAnError(t *testing.T) {
+	fake := &FakeExec{Results: []FakeCmdResult{
+		{Err: errors.New("blkid: error"), ExitCode: 1},
+	}}
+	fs := (&FS{}).WithExec(fake)
+
+	got, err := fs.getDiskFormat(context.Background(), "/dev/sdb")
+	if err == nil {
+		t.Fatal("expected an error for a non-2 blkid exit code")
+	}
+	if got != "" {
+		t.Fatalf("fsType = %q, want empty", got)
+	}
+}
+
+// withFakeDiskReadable overrides isDiskReadable for the duration of a
+// test, restoring it afterwards.
+func withFakeDiskReadable(t *testing.T, err error) {
+	t.Helper()
+	restore := isDiskReadable
+	isDiskReadable = func(string) error { return err }
+	t.Cleanup(func() { isDiskReadable = restore })
+}
+
+func TestGetDiskFormatBlankReadableDeviceIsNotAnError(t *testing.T) {
+	withFakeDiskReadable(t, nil)
+
+	fake := &FakeExec{Results: []FakeCmdResult{
+		{Err: errors.New("blkid: no recognized data"), ExitCode: 2},
+	}}
+	fs := (&FS{}).WithExec(fake)
+
+	got, err := fs.getDiskFormat(context.Background(), "/dev/sdb")
+	if err != nil {
+		t.Fatalf("getDiskFormat: %v", err)
+	}
+	if got != "" {
+		t.Fatalf("fsType = %q, want empty", got)
+	}
+}
+
+// TestGetDiskFormatUnreadableDeviceIsAnError is the regression case:
+// blkid's own exit code 2 covers both "genuinely blank" and "couldn't
+// access the device" (man blkid(8)), so a permission-denied or busy
+// device must not be reported as unformatted just because blkid also
+// exited 2.
+func TestGetDiskFormatUnreadableDeviceIsAnError(t *testing.T) {
+	withFakeDiskReadable(t, errors.New("permission denied"))
+
+	fake := &FakeExec{Results: []FakeCmdResult{
+		{Err: errors.New("blkid: no recognized data"), ExitCode: 2},
+	}}
+	fs := (&FS{}).WithExec(fake)
+
+	got, err := fs.getDiskFormat(context.Background(), "/dev/sdb")
+	if err == nil {
+		t.Fatal("expected an error for a device blkid could not actually read")
+	}
+	if got != "" {
+		t.Fatalf("fsType = %q, want empty", got)
+	}
+}
+
+func TestValidateMountOpts(t *testing.T) {
+	if err := validateMountOpts("xfs", []string{"nouuid", "inode64"}); err != nil {
+		t.Fatalf("xfs should accept its own options: %v", err)
+	}
+	if err := validateMountOpts("ext4", []string{"noatime"}); err != nil {
+		t.Fatalf("ordinary ext4 options should be accepted: %v", err)
+	}
+	if err := validateMountOpts("ext4", []string{"nouuid"}); err == nil {
+		t.Fatal("expected an error for an xfs-only option on ext4")
+	}
+}
+
+func TestResizeFSXFSNoop(t *testing.T) {
+	fake := &FakeExec{Results: []FakeCmdResult{{Output: []byte("data size unchanged\n")}}}
+	fs := (&FS{}).WithExec(fake)
+
+	if err := fs.ResizeFS(context.Background(), "/dev/sdb", "/mnt", "xfs"); err != nil {
+		t.Fatalf("ResizeFS: %v", err)
+	}
+	if len(fake.Invocations) != 1 || fake.Invocations[0].Name != "xfs_growfs" {
+		t.Fatalf("Invocations = %+v, want a single xfs_growfs call", fake.Invocations)
+	}
+	if fake.Invocations[0].Args[0] != "/mnt" {
+		t.Fatalf("xfs_growfs should target the mount path, got args %v", fake.Invocations[0].Args)
+	}
+}
+
+func TestResizeFSExt4(t *testing.T) {
+	fake := &FakeExec{Results: []FakeCmdResult{
+		{Output: []byte("The filesystem on /dev/sdb is now 100 blocks long\n")},
+	}}
+	fs := (&FS{}).WithExec(fake)
+
+	if err := fs.ResizeFS(context.Background(), "/dev/sdb", "/mnt", "ext4"); err != nil {
+		t.Fatalf("ResizeFS: %v", err)
+	}
+	if len(fake.Invocations) != 1 || fake.Invocations[0].Name != "resize2fs" {
+		t.Fatalf("Invocations = %+v, want a single resize2fs call", fake.Invocations)
+	}
+	if fake.Invocations[0].Args[0] != "/dev/sdb" {
+		t.Fatalf("resize2fs should target the device path, got args %v", fake.Invocations[0].Args)
+	}
+}
+
+func TestResizeFSFailure(t *testing.T) {
+	fake := &FakeExec{Results: []FakeCmdResult{
+		{Err: errors.New("xfs_growfs exited non-zero"), ExitCode: 1},
+	}}
+	fs := (&FS{}).WithExec(fake)
+
+	err := fs.ResizeFS(context.Background(), "/dev/sdb", "/mnt", "xfs")
+	var resizeErr *ErrResizeFailed
+	if !errors.As(err, &resizeErr) {
+		t.Fatalf("error = %v, want *ErrResizeFailed", err)
+	}
+}
+
+func TestFsckExitCodes(t *testing.T) {
+	cases := []struct {
+		name     string
+		exitCode int
+		wantErr  bool
+	}{
+		{"clean", 0, false},
+		{"corrected", 1, false},
+		{"uncorrected", 4, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var fake *FakeExec
+			if c.exitCode == 0 {
+				fake = &FakeExec{}
+			} else {
+				fake = &FakeExec{Results: []FakeCmdResult{
+					{Err: errors.New("fsck exited non-zero"), ExitCode: c.exitCode},
+				}}
+			}
+			fs := (&FS{}).WithExec(fake)
+
+			err := fs.Fsck(context.Background(), "/dev/sdb", "ext4")
+			if c.wantErr && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !c.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.wantErr {
+				var uncorrected *ErrFsckUncorrectedErrors
+				if !errors.As(err, &uncorrected) {
+					t.Fatalf("error = %v, want *ErrFsckUncorrectedErrors", err)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package gofsutil
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -21,34 +22,337 @@ var (
 	bindRemountOpts = []string{"remount"}
 )
 
-// getDiskFormat uses 'blkid' to see if the given disk is unformatted
+// isDiskReadable confirms disk can actually be opened and read. It is
+// overridden in tests; getDiskFormat uses it to tell a genuinely blank
+// device apart from one blkid merely couldn't access.
+var isDiskReadable = func(disk string) error {
+	f, err := os.Open(disk)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Read(make([]byte, 512)); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// fsckableTypes is the set of filesystem types that fsck knows how to
+// check; other types are skipped rather than rejected outright.
+var fsckableTypes = map[string]bool{
+	"ext2": true,
+	"ext3": true,
+	"ext4": true,
+	"xfs":  true,
+}
+
+// ErrFsckUncorrectedErrors is returned by Fsck (and formatAndMount, when
+// fsck is not disabled) when fsck(8) reports uncorrected filesystem
+// errors (exit code 4 or higher). Callers should treat the device as
+// unsafe to mount until an operator has intervened.
+type ErrFsckUncorrectedErrors struct {
+	Device   string
+	ExitCode int
+	Output   string
+}
+
+func (e *ErrFsckUncorrectedErrors) Error() string {
+	return fmt.Sprintf(
+		"fsck found uncorrected errors on %s (exit code %d): %s",
+		e.Device, e.ExitCode, e.Output)
+}
+
+// WithFsckOptions sets additional arguments passed to fsck ahead of the
+// device path, e.g. []string{"-y"} to auto-answer prompts.
+func (fs *FS) WithFsckOptions(args []string) *FS {
+	fs.fsckArgs = args
+	return fs
+}
+
+// Fsck runs fsck(8) against source, which is expected to already be
+// formatted as fsType. Per fsck(8), exit code 0 means the filesystem is
+// clean, 1 means errors were found and corrected, and either is safe to
+// proceed from. Exit codes of 4 or higher indicate errors fsck could
+// not correct, and are reported as an *ErrFsckUncorrectedErrors so
+// callers can decide policy.
+func (fs *FS) Fsck(ctx context.Context, source, fsType string) error {
+	if !fsckableTypes[fsType] {
+		return nil
+	}
+
+	args := append(append([]string{}, fs.fsckArgs...), source)
+	f := log.Fields{
+		"source": source,
+		"fsType": fsType,
+		"args":   args,
+	}
+	log.WithFields(f).Info("running fsck before mount")
+
+	out, err := fs.execer().CommandContext(ctx, "fsck", args...).CombinedOutput()
+	if err == nil {
+		log.WithFields(f).Info("fsck reported a clean filesystem")
+		return nil
+	}
+
+	exitCode := exitCodeOf(err)
+
+	switch {
+	case exitCode == 1:
+		log.WithFields(f).WithField("output", string(out)).Info(
+			"fsck corrected filesystem errors")
+		return nil
+	case exitCode >= 4:
+		log.WithFields(f).WithField("output", string(out)).Error(
+			"fsck found uncorrected filesystem errors")
+		return &ErrFsckUncorrectedErrors{
+			Device:   source,
+			ExitCode: exitCode,
+			Output:   string(out),
+		}
+	default:
+		log.WithFields(f).WithError(err).Error("fsck failed to run")
+		return err
+	}
+}
+
+// ErrResizeFailed is returned by ResizeFS when xfs_growfs/resize2fs
+// exits non-zero, as opposed to succeeding with the filesystem already
+// at its target size.
+type ErrResizeFailed struct {
+	Device string
+	FsType string
+	Output string
+	Err    error
+}
+
+func (e *ErrResizeFailed) Error() string {
+	return fmt.Sprintf(
+		"resize of %s (%s) failed: %v: %s", e.Device, e.FsType, e.Err, e.Output)
+}
+
+func (e *ErrResizeFailed) Unwrap() error {
+	return e.Err
+}
+
+// WithMkfsArgs sets extra arguments passed to mkfs ahead of the device
+// path. Currently only honored when formatting xfs.
+func (fs *FS) WithMkfsArgs(args []string) *FS {
+	fs.mkfsArgs = args
+	return fs
+}
+
+// WithMkfsBinary overrides the mkfs binary invoked for fsType, e.g. to
+// pin a specific mkfs.xfs build and avoid the well-known issue where a
+// host's xfsprogs version produces a filesystem the running kernel
+// cannot mount.
+func (fs *FS) WithMkfsBinary(fsType, path string) *FS {
+	if fs.mkfsBinaries == nil {
+		fs.mkfsBinaries = make(map[string]string)
+	}
+	fs.mkfsBinaries[fsType] = path
+	return fs
+}
+
+// mkfsBinary returns the mkfs binary to invoke for fsType, honoring any
+// override registered via WithMkfsBinary.
+func (fs *FS) mkfsBinary(fsType string) string {
+	if path, ok := fs.mkfsBinaries[fsType]; ok {
+		return path
+	}
+	return fmt.Sprintf("mkfs.%s", fsType)
+}
+
+// xfsMountOptions are mount options that only make sense for xfs; any
+// other fsType would have mount(8) reject the whole -o string outright
+// if one of these were included.
+var xfsMountOptions = map[string]bool{
+	"nouuid":  true,
+	"inode64": true,
+}
+
+// validateMountOpts rejects XFS-specific mount options when fsType
+// isn't xfs, so a caller's mistake surfaces as a clear error instead
+// of an opaque mount(8) failure.
+func validateMountOpts(fsType string, opts []string) error {
+	if fsType == "xfs" {
+		return nil
+	}
+	for _, o := range opts {
+		if xfsMountOptions[o] {
+			return fmt.Errorf("mount option %q is only valid for xfs, not %q", o, fsType)
+		}
+	}
+	return nil
+}
+
+// ResizeFS grows the filesystem at devicePath (already mounted at
+// mountPath) to fill the underlying block device. For xfs, which can
+// only be grown while mounted, it runs xfs_growfs against mountPath;
+// for the ext family it runs resize2fs against devicePath. A non-zero
+// exit from either tool is reported as an *ErrResizeFailed; an
+// already-at-target-size filesystem is treated as success and logged.
+func (fs *FS) ResizeFS(
+	ctx context.Context,
+	devicePath, mountPath, fsType string) error {
+
+	f := log.Fields{
+		"device":    devicePath,
+		"mountPath": mountPath,
+		"fsType":    fsType,
+	}
+
+	var cmd Cmd
+	switch fsType {
+	case "xfs":
+		cmd = fs.execer().CommandContext(ctx, "xfs_growfs", mountPath)
+	case "ext2", "ext3", "ext4":
+		cmd = fs.execer().CommandContext(ctx, "resize2fs", devicePath)
+	default:
+		return fmt.Errorf("resize is not supported for fsType %q", fsType)
+	}
+
+	buf, err := cmd.CombinedOutput()
+	out := string(buf)
+	if err != nil {
+		log.WithFields(f).WithError(err).WithField("output", out).Error(
+			"filesystem resize failed")
+		return &ErrResizeFailed{Device: devicePath, FsType: fsType, Output: out, Err: err}
+	}
+
+	if resizeWasNoop(fsType, out) {
+		log.WithFields(f).WithField("output", out).Info(
+			"filesystem already at target size")
+		return nil
+	}
+
+	log.WithFields(f).WithField("output", out).Info("filesystem resized")
+	return nil
+}
+
+// resizeWasNoop reports whether the growfs/resize2fs output indicates
+// the filesystem was already at its target size.
+func resizeWasNoop(fsType, output string) bool {
+	if fsType == "xfs" {
+		return strings.Contains(output, "data size unchanged")
+	}
+	return strings.Contains(output, "Nothing to do")
+}
+
+// FormatMismatchError is returned by formatAndMount when a device is
+// already formatted with a filesystem other than the one requested,
+// and mounting it as the requested type failed.
+type FormatMismatchError struct {
+	Device    string
+	Requested string
+	Existing  string
+	Err       error
+}
+
+func (e *FormatMismatchError) Error() string {
+	return fmt.Sprintf(
+		"failed to mount volume %s as %q; already contains %s: %v",
+		e.Device, e.Requested, e.Existing, e.Err)
+}
+
+func (e *FormatMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// ErrPartitionTableDetected is returned by getDiskFormat/GetDiskFormat
+// when a device carries a partition table but no filesystem directly
+// on it, so callers can refuse to mkfs over a partitioned disk instead
+// of silently destroying the partition table.
+type ErrPartitionTableDetected struct {
+	Device string
+	PTType string
+}
+
+func (e *ErrPartitionTableDetected) Error() string {
+	return fmt.Sprintf(
+		"device %s has a %s partition table; refusing to treat it as unformatted",
+		e.Device, e.PTType)
+}
+
+// GetDiskFormat reports the filesystem type already present on device,
+// probed with blkid. It returns ("", nil) for a genuinely blank device.
+func (fs *FS) GetDiskFormat(ctx context.Context, device string) (string, error) {
+	return fs.getDiskFormat(ctx, device)
+}
+
+// parseBlkidExport parses the KEY=VALUE lines produced by
+// `blkid -o export` into a map.
+func parseBlkidExport(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[k] = v
+	}
+	return values
+}
+
+// getDiskFormat uses 'blkid' to see if the given disk is unformatted,
+// already has a recognized filesystem on it, or merely carries a
+// partition table (see ErrPartitionTableDetected). A blkid exit code of
+// 2 is treated as a genuinely blank disk only if isDiskReadable can read
+// the device, and is returned as an error otherwise; any other non-zero
+// exit (e.g. device busy or a permissions problem) is likewise returned
+// as a real error instead of being treated as unformatted,
+// since the latter previously caused formatAndMount to attempt a
+// destructive mkfs on a device it merely could not read.
 func (fs *FS) getDiskFormat(ctx context.Context, disk string) (string, error) {
 
-	args := []string{"-s", "TYPE", "-o", "value", disk}
+	args := []string{"-p", "-o", "export", disk}
 
 	f := log.Fields{
 		"disk": disk,
 	}
 	log.WithFields(f).WithField("args", args).Info(
 		"checking if disk is formatted using blkid")
-	buf, err := exec.Command("blkid", args...).CombinedOutput()
+	buf, err := fs.execer().CommandContext(ctx, "blkid", args...).CombinedOutput()
 	out := string(buf)
 	log.WithField("output", out).Debug("blkid output")
 
 	if err != nil {
+		if exitCodeOf(err) == 2 {
+			// blkid(8) returns exit code 2 both for "no recognized data
+			// on the device" (a genuinely blank disk) and for "it was
+			// impossible to gather any information" (e.g. a permission
+			// or I/O problem reading the device) -- the two cases are
+			// not distinguishable from the exit code alone. Confirm we
+			// can actually read the device ourselves before trusting
+			// this as "blank"; otherwise treat it as the read failure
+			// it is, so formatAndMount doesn't mkfs over a device it
+			// merely couldn't access.
+			if readErr := isDiskReadable(disk); readErr != nil {
+				log.WithFields(f).WithError(readErr).Error(
+					"blkid reported no data, but disk could not be read")
+				return "", fmt.Errorf("failed to probe disk %s: %v", disk, readErr)
+			}
+			log.WithFields(f).Info("no filesystem found on disk")
+			return "", nil
+		}
 		log.WithFields(f).WithError(err).Error(
-			"no filesystem found on disk")
-		return "", nil
+			"failed to probe disk with blkid")
+		return "", fmt.Errorf("failed to probe disk %s with blkid: %v: %s", disk, err, out)
 	}
 
-	fsType := strings.TrimSpace(out)
-	if fsType != "" {
+	values := parseBlkidExport(out)
+	if fsType := values["TYPE"]; fsType != "" {
 		// The device is formatted
 		log.WithFields(f).WithField("fsType", fsType).Info(
 			"disk is already formatted")
 		return fsType, nil
 	}
 
+	if ptType := values["PTTYPE"]; ptType != "" {
+		return "", &ErrPartitionTableDetected{Device: disk, PTType: ptType}
+	}
+
 	// The device is unformatted
 	return "", nil
 }
@@ -80,15 +384,20 @@ func (fs *FS) formatAndMount(
 			fsType = "ext4"
 		}
 
-		if fsType == "ext4" || fsType == "ext3" {
+		switch fsType {
+		case "ext4", "ext3":
 			args = []string{"-F", source}
+		case "xfs":
+			// mkfs.xfs takes -f, not the ext family's -F, and accepts
+			// caller-supplied arguments (e.g. to pick a geometry).
+			args = append(append([]string{"-f"}, fs.mkfsArgs...), source)
 		}
 		f["fsType"] = fsType
 		log.WithFields(f).Info(
 			"disk appears unformatted, attempting format")
 
-		mkfsCmd := fmt.Sprintf("mkfs.%s", fsType)
-		if err := exec.Command(mkfsCmd, args...).Run(); err != nil {
+		mkfsCmd := fs.mkfsBinary(fsType)
+		if err := fs.execer().CommandContext(ctx, mkfsCmd, args...).Run(); err != nil {
 			log.WithFields(f).WithError(err).Error(
 				"format of disk failed")
 			return err
@@ -97,9 +406,33 @@ func (fs *FS) formatAndMount(
 		// the disk has been formatted successfully try to mount it.
 		log.WithFields(f).Info(
 			"disk successfully formatted")
+		if err := validateMountOpts(fsType, opts); err != nil {
+			return err
+		}
 		return fs.mount(ctx, source, target, fsType, opts...)
 	}
 
+	// The disk is already formatted; fsck it before mounting unless
+	// fsck has been disabled or this is a read-only mount, since
+	// neither can safely run or correct a filesystem. Bind mounts never
+	// reach this point at all -- they go through bindMount, which calls
+	// doMount directly and never formatAndMount/Fsck.
+	if !fs.DisableFsck && !isReadOnly(opts) {
+		if err := fs.Fsck(ctx, source, existingFormat); err != nil {
+			return err
+		}
+	}
+
+	// Mount options like nouuid/inode64 are xfs-specific; validate
+	// against whichever fsType actually ends up being mounted.
+	mountFsType := fsType
+	if mountFsType == "" {
+		mountFsType = existingFormat
+	}
+	if err := validateMountOpts(mountFsType, opts); err != nil {
+		return err
+	}
+
 	// Try to mount the disk
 	log.WithFields(f).WithField("existingFSType", existingFormat).Info("attempting to mount disk")
 	mountErr := fs.mount(ctx, source, target, fsType, opts...)
@@ -114,9 +447,100 @@ func (fs *FS) formatAndMount(
 	}
 
 	// Block device is formatted with unexpected filesystem
-	return fmt.Errorf(
-		"failed to mount volume as %q; already contains %s: error: %v",
-		fsType, existingFormat, mountErr)
+	return &FormatMismatchError{
+		Device:    source,
+		Requested: fsType,
+		Existing:  existingFormat,
+		Err:       mountErr,
+	}
+}
+
+// systemdAvailable reports whether this host can run mounts inside a
+// transient systemd scope: it must be running systemd, and systemd-run
+// must be on PATH.
+func systemdAvailable() bool {
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		return false
+	}
+	_, err := exec.LookPath("systemd-run")
+	return err == nil
+}
+
+// WithSystemdScope enables running mounts inside a transient systemd
+// scope unit named "<unitPrefix>.scope". When a long-running container
+// process is the "mount owner", killing that container tears down the
+// mount's cgroup and can leave stale mounts behind; scoping the mount
+// into its own transient unit detaches it from the caller's cgroup.
+// It is a no-op on hosts without systemd or systemd-run, which fall
+// back transparently to a direct mount exec.
+func (fs *FS) WithSystemdScope(unitPrefix string) *FS {
+	fs.systemdUnitPrefix = unitPrefix
+	fs.useSystemdScope = systemdAvailable()
+	return fs
+}
+
+// wrapMountCommand rewrites mountCmd/args to run inside a transient
+// systemd scope when systemd scoping is enabled, leaving them untouched
+// otherwise.
+func (fs *FS) wrapMountCommand(mountCmd string, args []string) (string, []string) {
+	if !fs.useSystemdScope {
+		return mountCmd, args
+	}
+	wrapped := append(
+		[]string{"--scope", "--unit", fs.systemdUnitPrefix + ".scope", "--", mountCmd},
+		args...)
+	return "systemd-run", wrapped
+}
+
+// mount runs the "mount" binary with fsType and opts against source
+// and target.
+func (fs *FS) mount(
+	ctx context.Context,
+	source, target, fsType string,
+	opts ...string) error {
+
+	return fs.doMount(ctx, "mount", source, target, fsType, opts...)
+}
+
+// doMount runs mountCmd with the arguments mount(8) expects, wrapping
+// it in a systemd scope first if one was configured via
+// WithSystemdScope.
+func (fs *FS) doMount(
+	ctx context.Context,
+	mountCmd, source, target, fsType string,
+	opts ...string) error {
+
+	args := make([]string, 0, len(opts)+4)
+	if len(fsType) > 0 {
+		args = append(args, "-t", fsType)
+	}
+	if len(opts) > 0 {
+		args = append(args, "-o", strings.Join(opts, ","))
+	}
+	args = append(args, source, target)
+
+	bin, args := fs.wrapMountCommand(mountCmd, args)
+
+	f := log.Fields{"cmd": bin, "args": args}
+	log.WithFields(f).Info("mounting")
+
+	out, err := fs.execer().CommandContext(ctx, bin, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf(
+			"mount failed: %v\nmounting arguments: %s\noutput: %s",
+			err, strings.Join(args, " "), string(out))
+	}
+	return nil
+}
+
+// isReadOnly returns true if opts requests a read-only mount.
+func isReadOnly(opts []string) bool {
+	for _, o := range opts {
+		if o == "ro" {
+			return true
+		}
+	}
+	return false
 }
 
 // bindMount performs a bind mount
@@ -132,16 +556,27 @@ func (fs *FS) bindMount(
 	return fs.doMount(ctx, "mount", source, target, "", opts...)
 }
 
+// mountsPathOrDefault returns fs.mountsPath if one was set (as tests
+// do, to point getMounts at a fixture), otherwise procMountsPath.
+func (fs *FS) mountsPathOrDefault() string {
+	if fs.mountsPath != "" {
+		return fs.mountsPath
+	}
+	return procMountsPath
+}
+
 // getMounts returns a slice of all the mounted filesystems
 func (fs *FS) getMounts(ctx context.Context) ([]Info, error) {
 
-	_, hash1, err := fs.readProcMounts(ctx, procMountsPath, false)
+	path := fs.mountsPathOrDefault()
+
+	_, hash1, err := fs.readProcMounts(ctx, path, false)
 	if err != nil {
 		return nil, err
 	}
 
 	for i := 0; i < procMountsRetries; i++ {
-		mps, hash2, err := fs.readProcMounts(ctx, procMountsPath, true)
+		mps, hash2, err := fs.readProcMounts(ctx, path, true)
 		if err != nil {
 			return nil, err
 		}
@@ -153,7 +588,66 @@ func (fs *FS) getMounts(ctx context.Context) ([]Info, error) {
 	}
 	return nil, fmt.Errorf(
 		"failed to get a consistent snapshot of %v after %d tries",
-		procMountsPath, procMountsRetries)
+		path, procMountsRetries)
+}
+
+// isSharedOptionalFields reports whether any of a mount's optional
+// fields marks it as belonging to a shared peer group, per
+// mountinfo(5) (e.g. "shared:2").
+func isSharedOptionalFields(fields []string) bool {
+	for _, f := range fields {
+		if strings.HasPrefix(f, "shared:") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsShared reports whether mountPath is mounted in a shared peer group,
+// meaning mount/unmount events on it propagate to and from its peers.
+// This matters for NodeUnstageVolume-style cleanup, since unmounting a
+// shared mount can affect other mount namespaces.
+func (fs *FS) IsShared(ctx context.Context, mountPath string) (bool, error) {
+	mounts, err := fs.getMounts(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range mounts {
+		if m.Path == mountPath {
+			return isSharedOptionalFields(m.OptionalFields), nil
+		}
+	}
+	return false, fmt.Errorf("mount point %q not found", mountPath)
+}
+
+// GetMountRefs returns every other mount point backed by the same
+// block device (major:minor) as mountPath. This is needed to safely
+// unmount a bind-mounted block device without leaving dangling mounts
+// of the same device elsewhere.
+func (fs *FS) GetMountRefs(ctx context.Context, mountPath string) ([]string, error) {
+	mounts, err := fs.getMounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var majorMinor string
+	for _, m := range mounts {
+		if m.Path == mountPath {
+			majorMinor = m.MajorMinor
+			break
+		}
+	}
+	if majorMinor == "" {
+		return nil, fmt.Errorf("mount point %q not found", mountPath)
+	}
+
+	var refs []string
+	for _, m := range mounts {
+		if m.MajorMinor == majorMinor && m.Path != mountPath {
+			refs = append(refs, m.Path)
+		}
+	}
+	return refs, nil
 }
 
 // readProcMounts reads procMountsInfo and produce a hash
@@ -169,5 +663,5 @@ func (fs *FS) readProcMounts(
 	}
 	defer file.Close()
 
-	return ReadProcMountsFrom(ctx, file, !info, ProcMountsFields, fs.ScanEntry)
+	return ReadProcMountsFrom(ctx, file, !info, ProcMountsFields, fs.scanEntry())
 }
@@ -0,0 +1,84 @@
+package gofsutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestMountinfo writes content (one mountinfo(5) line per string)
+// to a temp file and returns its path, for use as FS.mountsPath.
+func writeTestMountinfo(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetMountRefs(t *testing.T) {
+	path := writeTestMountinfo(t,
+		"36 35 8:1 / /data rw shared:1 - ext4 /dev/sdb1 rw",
+		"37 35 8:1 / /data-bind rw shared:1 - ext4 /dev/sdb1 rw",
+		"38 35 8:2 / /other rw - ext4 /dev/sdc1 rw",
+	)
+	fs := &FS{ScanEntry: defaultEntryScanFunc, mountsPath: path}
+
+	refs, err := fs.GetMountRefs(context.Background(), "/data")
+	if err != nil {
+		t.Fatalf("GetMountRefs: %v", err)
+	}
+	if len(refs) != 1 || refs[0] != "/data-bind" {
+		t.Fatalf("refs = %v, want [/data-bind]", refs)
+	}
+
+	if _, err := fs.GetMountRefs(context.Background(), "/missing"); err == nil {
+		t.Fatal("expected an error for an unknown mount point")
+	}
+}
+
+func TestIsShared(t *testing.T) {
+	path := writeTestMountinfo(t,
+		"36 35 8:1 / /data rw shared:1 - ext4 /dev/sdb1 rw",
+		"38 35 8:2 / /private rw master:1 - ext4 /dev/sdc1 rw",
+	)
+	fs := &FS{ScanEntry: defaultEntryScanFunc, mountsPath: path}
+
+	shared, err := fs.IsShared(context.Background(), "/data")
+	if err != nil || !shared {
+		t.Fatalf("IsShared(/data) = %v, %v, want true, nil", shared, err)
+	}
+
+	shared, err = fs.IsShared(context.Background(), "/private")
+	if err != nil || shared {
+		t.Fatalf("IsShared(/private) = %v, %v, want false, nil", shared, err)
+	}
+
+	if _, err := fs.IsShared(context.Background(), "/missing"); err == nil {
+		t.Fatal("expected an error for an unknown mount point")
+	}
+}
+
+// TestGetMountRefsZeroValueFS locks in that a bare &FS{} (no ScanEntry
+// set, as real callers construct one) works rather than panicking on a
+// nil ScanEntry.
+func TestGetMountRefsZeroValueFS(t *testing.T) {
+	path := writeTestMountinfo(t,
+		"36 35 8:1 / /data rw shared:1 - ext4 /dev/sdb1 rw",
+		"37 35 8:1 / /data-bind rw shared:1 - ext4 /dev/sdb1 rw",
+	)
+	fs := &FS{mountsPath: path}
+
+	refs, err := fs.GetMountRefs(context.Background(), "/data")
+	if err != nil {
+		t.Fatalf("GetMountRefs: %v", err)
+	}
+	if len(refs) != 1 || refs[0] != "/data-bind" {
+		t.Fatalf("refs = %v, want [/data-bind]", refs)
+	}
+}
@@ -0,0 +1,52 @@
+package gofsutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDoMountSystemdScope(t *testing.T) {
+	fake := &FakeExec{}
+	fs := (&FS{useSystemdScope: true, systemdUnitPrefix: "gofsutil-mount"}).WithExec(fake)
+
+	if err := fs.doMount(context.Background(), "mount", "/dev/sdb", "/mnt", "ext4"); err != nil {
+		t.Fatalf("doMount: %v", err)
+	}
+
+	if len(fake.Invocations) != 1 {
+		t.Fatalf("Invocations = %d, want 1", len(fake.Invocations))
+	}
+	got := fake.Invocations[0]
+	if got.Name != "systemd-run" {
+		t.Fatalf("Name = %q, want systemd-run", got.Name)
+	}
+	want := []string{
+		"--scope", "--unit", "gofsutil-mount.scope", "--",
+		"mount", "-t", "ext4", "/dev/sdb", "/mnt",
+	}
+	if !reflect.DeepEqual(got.Args, want) {
+		t.Fatalf("Args = %v, want %v", got.Args, want)
+	}
+}
+
+func TestDoMountWithoutSystemdScope(t *testing.T) {
+	fake := &FakeExec{}
+	fs := (&FS{}).WithExec(fake)
+
+	if err := fs.doMount(context.Background(), "mount", "/dev/sdb", "/mnt", "ext4"); err != nil {
+		t.Fatalf("doMount: %v", err)
+	}
+
+	if len(fake.Invocations) != 1 {
+		t.Fatalf("Invocations = %d, want 1", len(fake.Invocations))
+	}
+	got := fake.Invocations[0]
+	if got.Name != "mount" {
+		t.Fatalf("Name = %q, want mount (no systemd-run wrapper)", got.Name)
+	}
+	want := []string{"-t", "ext4", "/dev/sdb", "/mnt"}
+	if !reflect.DeepEqual(got.Args, want) {
+		t.Fatalf("Args = %v, want %v", got.Args, want)
+	}
+}
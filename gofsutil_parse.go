@@ -0,0 +1,102 @@
+package gofsutil
+
+import (
+	"bufio"
+	"context"
+	"hash/fnv"
+	"io"
+	"strings"
+)
+
+// ProcMountsFields is the minimum number of whitespace separated
+// fields a /proc/self/mountinfo entry is expected to have: mount ID,
+// parent ID, major:minor, root, mount point, mount options, the "-"
+// optional-fields terminator, fs type, source, and super options.
+const ProcMountsFields = 10
+
+// ReadProcMountsFrom parses mount table entries read from r, one per
+// line, handing each line to scan, and returns the parsed entries
+// together with a hash of the whole content. If hashOnly is true the
+// lines are hashed but not parsed, and the returned Info slice is nil.
+func ReadProcMountsFrom(
+	ctx context.Context,
+	r io.Reader,
+	hashOnly bool,
+	expectedFields int,
+	scan EntryScanFunc) ([]Info, uint32, error) {
+
+	var (
+		mounts []Info
+		hash   = fnv.New32a()
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		hash.Write([]byte(line))
+		hash.Write([]byte{'\n'})
+		if hashOnly {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < expectedFields {
+			continue
+		}
+
+		info, valid := scan(ctx, line)
+		if !valid {
+			continue
+		}
+		mounts = append(mounts, info)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return mounts, hash.Sum32(), nil
+}
+
+// defaultEntryScanFunc parses a single /proc/self/mountinfo line per
+// mountinfo(5):
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+//	(1)(2)(3)   (4)   (5)      (6)      (7)   (8) (9)   (10)         (11)
+//
+// (1) mount ID, (2) parent ID, (3) major:minor, (4) root, (5) mount
+// point, (6) mount options, (7) zero or more optional fields, (8) the
+// "-" separator, (9) filesystem type, (10) mount source, (11) super
+// options.
+func defaultEntryScanFunc(ctx context.Context, entry string) (Info, bool) {
+	fields := strings.Fields(entry)
+	if len(fields) < ProcMountsFields {
+		return Info{}, false
+	}
+
+	sepIdx := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sepIdx = i
+			break
+		}
+	}
+	// The separator plus fs type, source and super options must follow.
+	if sepIdx == -1 || len(fields) < sepIdx+4 {
+		return Info{}, false
+	}
+
+	info := Info{
+		MountID:        fields[0],
+		ParentID:       fields[1],
+		MajorMinor:     fields[2],
+		Root:           fields[3],
+		Path:           fields[4],
+		Opts:           strings.Split(fields[5], ","),
+		OptionalFields: append([]string(nil), fields[6:sepIdx]...),
+		Type:           fields[sepIdx+1],
+		Source:         fields[sepIdx+2],
+		Device:         fields[sepIdx+2],
+		SuperOptions:   strings.Split(fields[sepIdx+3], ","),
+	}
+	return info, true
+}
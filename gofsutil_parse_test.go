@@ -0,0 +1,54 @@
+package gofsutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultEntryScanFunc(t *testing.T) {
+	line := "36 35 98:0 / /mnt1 rw,noatime shared:2 - ext3 /dev/root rw,errors=continue"
+
+	info, valid := defaultEntryScanFunc(context.Background(), line)
+	if !valid {
+		t.Fatal("expected a valid entry")
+	}
+
+	want := Info{
+		MountID:        "36",
+		ParentID:       "35",
+		MajorMinor:     "98:0",
+		Root:           "/",
+		Path:           "/mnt1",
+		Opts:           []string{"rw", "noatime"},
+		OptionalFields: []string{"shared:2"},
+		Type:           "ext3",
+		Source:         "/dev/root",
+		Device:         "/dev/root",
+		SuperOptions:   []string{"rw", "errors=continue"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("info = %+v, want %+v", info, want)
+	}
+}
+
+func TestDefaultEntryScanFuncNoOptionalFields(t *testing.T) {
+	line := "36 35 98:0 / /mnt1 rw,noatime - ext3 /dev/root rw"
+
+	info, valid := defaultEntryScanFunc(context.Background(), line)
+	if !valid {
+		t.Fatal("expected a valid entry")
+	}
+	if len(info.OptionalFields) != 0 {
+		t.Fatalf("OptionalFields = %v, want none", info.OptionalFields)
+	}
+}
+
+func TestIsSharedOptionalFields(t *testing.T) {
+	if isSharedOptionalFields([]string{"master:1"}) {
+		t.Fatal("master-only peer group should not be reported as shared")
+	}
+	if !isSharedOptionalFields([]string{"shared:2", "master:1"}) {
+		t.Fatal("expected shared:2 to be detected")
+	}
+}